@@ -0,0 +1,89 @@
+// Package app wires server.Options to the gotty command-line interface.
+package app
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+
+	"github.com/yudai/gotty/server"
+)
+
+// GenerateFlags reflects over a *server.Options and produces one
+// cli.Flag per exported field carrying a flagName tag. A field gets a
+// flag the moment it's added to server.Options with that tag — there's
+// no separate per-flag list to keep in sync as the Options struct grows.
+func GenerateFlags(options *server.Options) ([]cli.Flag, error) {
+	var flags []cli.Flag
+
+	v := reflect.ValueOf(options).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := field.Tag.Get("flagName")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		names := name
+		if sName := field.Tag.Get("flagSName"); sName != "" {
+			names += ", " + sName
+		}
+		describe := field.Tag.Get("flagDescribe")
+		def := field.Tag.Get("default")
+
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			flags = append(flags, cli.BoolFlag{Name: names, Usage: describe})
+		case reflect.Int:
+			n, _ := strconv.Atoi(def)
+			flags = append(flags, cli.IntFlag{Name: names, Value: n, Usage: describe})
+		case reflect.Slice:
+			flags = append(flags, cli.StringSliceFlag{Name: names, Usage: describe})
+		case reflect.String:
+			flags = append(flags, cli.StringFlag{Name: names, Value: def, Usage: describe})
+		default:
+			return nil, errors.Errorf("unsupported flag field type for Options.%s: %s", field.Name, field.Type.Kind())
+		}
+	}
+
+	return flags, nil
+}
+
+// ApplyFlags copies every flag c.IsSet on the invoking command line back
+// into options, keyed by the same flagName tag GenerateFlags read them
+// from. Flags the caller didn't pass are left at whatever options
+// already held (its zero value, or a value loaded from a config file).
+func ApplyFlags(c *cli.Context, options *server.Options) {
+	v := reflect.ValueOf(options).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("flagName")
+		if name == "" || name == "-" || !c.IsSet(name) {
+			continue
+		}
+
+		fv := v.Field(i)
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			fv.SetBool(c.Bool(name))
+		case reflect.Int:
+			fv.SetInt(int64(c.Int(name)))
+		case reflect.Slice:
+			fv.Set(reflect.ValueOf(c.StringSlice(name)))
+		case reflect.String:
+			fv.SetString(c.String(name))
+		}
+	}
+
+	// EnableBasicAuth has no flag of its own: Basic Auth is on whenever
+	// a credential was configured, rather than needing a second flag
+	// that has to agree with Credential being set.
+	options.EnableBasicAuth = options.Credential != ""
+}
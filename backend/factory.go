@@ -0,0 +1,31 @@
+// Package backend defines the interface gotty uses to spawn the process
+// behind each WebSocket connection.
+package backend
+
+import (
+	"github.com/yudai/gotty/webtty"
+)
+
+// Factory creates a new Slave for each incoming WebSocket connection.
+//
+// params carries request-scoped values resolved by the server for this
+// particular connection: the query string of the WebSocket request
+// (see webtty.InitMessage) merged with any identity the server verified
+// out-of-band, such as GOTTY_USER and GOTTY_CERT_CN from a client
+// certificate. Implementations that want to honor the caller's identity,
+// e.g. to choose a shell or to pass it along via `su`/`sudo`, read it
+// from params rather than from the connection directly.
+type Factory interface {
+	// Name returns a human-readable name of this backend, used in
+	// startup logs.
+	Name() string
+
+	// New spawns a new Slave for a single WebSocket connection.
+	New(params map[string]string) (webtty.Slave, error)
+
+	// HealthCheck reports whether this factory is currently able to
+	// spawn sessions, e.g. that its configured command still exists and
+	// is executable. It backs the factory component of /readyz and
+	// should be cheap enough to call on every readiness probe.
+	HealthCheck() error
+}
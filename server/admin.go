@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AdminServer is the session/admin control plane exposed over gRPC
+// alongside the regular HTTP/WebSocket traffic, on the same port (see
+// mux.go). It lets an operator inspect and manage a running gotty
+// server without SSHing in or restarting it.
+type AdminServer interface {
+	ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error)
+	KillSession(ctx context.Context, req *KillSessionRequest) (*KillSessionResponse, error)
+	ReloadTLS(ctx context.Context, req *ReloadTLSRequest) (*ReloadTLSResponse, error)
+}
+
+type ListSessionsRequest struct{}
+
+// SessionInfo describes one live PTY connection.
+type SessionInfo struct {
+	RemoteAddr string `json:"remote_addr"`
+	User       string `json:"user,omitempty"`
+}
+
+type ListSessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type KillSessionRequest struct {
+	RemoteAddr string `json:"remote_addr"`
+}
+
+type KillSessionResponse struct {
+	Killed bool `json:"killed"`
+}
+
+type ReloadTLSRequest struct{}
+
+type ReloadTLSResponse struct {
+	Reloaded bool `json:"reloaded"`
+}
+
+// adminService implements AdminServer against a live *Server.
+type adminService struct {
+	server *Server
+}
+
+func (a *adminService) ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return &ListSessionsResponse{Sessions: a.server.sessionSnapshot()}, nil
+}
+
+func (a *adminService) KillSession(ctx context.Context, req *KillSessionRequest) (*KillSessionResponse, error) {
+	return &KillSessionResponse{Killed: a.server.killSession(req.RemoteAddr)}, nil
+}
+
+func (a *adminService) ReloadTLS(ctx context.Context, req *ReloadTLSRequest) (*ReloadTLSResponse, error) {
+	if err := a.server.reloadTLS(); err != nil {
+		return nil, err
+	}
+	return &ReloadTLSResponse{Reloaded: true}, nil
+}
+
+// requireAdminToken is a grpc.UnaryServerInterceptor gating the admin
+// service independently of the main HTTP auth chain (TLS client certs,
+// --auth-header, --auth-oidc-jwks). Those all authenticate the
+// WebSocket path wired into setupHandlers's HTTP mux; cmux hands the
+// admin service its own matched listener in mux.go, so without a check
+// here anyone who can reach gotty's port at all — including over plain
+// HTTP, or TLS with only a server cert — could list and kill other
+// users' sessions or force a TLS reload. AdminToken must be set for any
+// call to succeed; an unconfigured token fails closed rather than
+// leaving the control plane open.
+func (server *Server) requireAdminToken(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if server.options.AdminToken == "" {
+		return nil, status.Error(codes.Unauthenticated, "admin API is disabled: no AdminToken configured")
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing admin token")
+	}
+
+	tokens := md.Get("x-admin-token")
+	if len(tokens) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(server.options.AdminToken)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid admin token")
+	}
+
+	return handler(ctx, req)
+}
+
+// RegisterAdminServer wires an AdminServer implementation into a
+// *grpc.Server. The admin API intentionally has no .proto of its own:
+// its messages are plain Go structs carried as JSON over gRPC's
+// method-call framing, which keeps the control plane self-contained. A
+// deployment that needs cross-language admin clients should replace
+// this with generated protobuf bindings instead.
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&adminServiceDesc, srv)
+}
+
+var adminServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gotty.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSessions",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(ListSessionsRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(AdminServer).ListSessions(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/gotty.Admin/ListSessions"}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(AdminServer).ListSessions(ctx, req.(*ListSessionsRequest))
+				}
+				return interceptor(ctx, req, info, handler)
+			},
+		},
+		{
+			MethodName: "KillSession",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(KillSessionRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(AdminServer).KillSession(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/gotty.Admin/KillSession"}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(AdminServer).KillSession(ctx, req.(*KillSessionRequest))
+				}
+				return interceptor(ctx, req, info, handler)
+			},
+		},
+		{
+			MethodName: "ReloadTLS",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(ReloadTLSRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(AdminServer).ReloadTLS(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/gotty.Admin/ReloadTLS"}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(AdminServer).ReloadTLS(ctx, req.(*ReloadTLSRequest))
+				}
+				return interceptor(ctx, req, info, handler)
+			},
+		},
+	},
+	Metadata: "admin",
+}
+
+// jsonCodec marshals the admin service's plain Go structs as JSON so it
+// can run without a protobuf toolchain. It implements the
+// encoding.Codec interface (Name, not the older Codec interface's
+// String) and is registered on the admin server's grpc.Server via
+// grpc.ForceServerCodec — grpc.CustomCodec and the String()-based Codec
+// interface it expects are removed in current google.golang.org/grpc
+// releases.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
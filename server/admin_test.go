@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func noopHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestRequireAdminTokenRejectsWhenUnconfigured(t *testing.T) {
+	server := &Server{options: &Options{}}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-admin-token", "anything"))
+	_, err := server.requireAdminToken(ctx, nil, nil, noopHandler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated with no AdminToken configured, got %v", err)
+	}
+}
+
+func TestRequireAdminTokenRejectsMissingMetadata(t *testing.T) {
+	server := &Server{options: &Options{AdminToken: "s3cr3t"}}
+
+	_, err := server.requireAdminToken(context.Background(), nil, nil, noopHandler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated with no metadata on the context, got %v", err)
+	}
+}
+
+func TestRequireAdminTokenRejectsWrongToken(t *testing.T) {
+	server := &Server{options: &Options{AdminToken: "s3cr3t"}}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-admin-token", "wrong"))
+	_, err := server.requireAdminToken(ctx, nil, nil, noopHandler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated with a mismatched token, got %v", err)
+	}
+}
+
+func TestRequireAdminTokenAcceptsMatchingToken(t *testing.T) {
+	server := &Server{options: &Options{AdminToken: "s3cr3t"}}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-admin-token", "s3cr3t"))
+	resp, err := server.requireAdminToken(ctx, nil, nil, noopHandler)
+	if err != nil {
+		t.Fatalf("expected a matching token to be accepted, got error: %s", err)
+	}
+	if resp != "ok" {
+		t.Fatalf("expected the wrapped handler's response to pass through, got %v", resp)
+	}
+}
+
+func TestJSONCodecRoundTrips(t *testing.T) {
+	codec := jsonCodec{}
+
+	req := &KillSessionRequest{RemoteAddr: "127.0.0.1:1234"}
+	data, err := codec.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+
+	var decoded KillSessionRequest
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if decoded.RemoteAddr != req.RemoteAddr {
+		t.Fatalf("expected RemoteAddr %q, got %q", req.RemoteAddr, decoded.RemoteAddr)
+	}
+
+	if codec.Name() != "json" {
+		t.Fatalf("expected codec name %q, got %q", "json", codec.Name())
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+)
+
+// authenticator resolves the caller's identity for a single HTTP
+// request. Returning (nil, nil) means this authenticator has no
+// opinion about the request, so resolveIdentity falls through to the
+// next one in the chain; a non-nil error rejects the request outright.
+type authenticator interface {
+	authenticate(r *http.Request) (*connectionIdentity, error)
+}
+
+// resolveIdentity runs the server's configured auth chain in order —
+// verified client certificate, then trusted forwarded-header, then
+// OIDC bearer token — and returns the first identity a mode resolves.
+// generateHandleWS merges the result into the params handed to the
+// backend factory the same way it already does for TLSUserHeader.
+func (server *Server) resolveIdentity(r *http.Request) (*connectionIdentity, error) {
+	for _, auth := range server.authenticators() {
+		identity, err := auth.authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+// authenticators builds the chain according to whichever auth options
+// are configured. The order is fixed (client certificate, forwarded
+// header, OIDC bearer token) and the first mode that resolves an
+// identity wins; it cannot be changed through the options.
+func (server *Server) authenticators() []authenticator {
+	var chain []authenticator
+	if server.options.EnableTLSClientAuth {
+		chain = append(chain, certAuthenticator{server: server})
+	}
+	if server.options.AuthHeader != "" {
+		chain = append(chain, headerAuthenticator{server: server})
+	}
+	if server.options.AuthOIDCJWKSURL != "" {
+		chain = append(chain, server.oidcAuthenticator())
+	}
+	return chain
+}
+
+// certAuthenticator adapts the existing client-certificate identity
+// resolution (see identity.go) to the authenticator interface.
+type certAuthenticator struct {
+	server *Server
+}
+
+func (a certAuthenticator) authenticate(r *http.Request) (*connectionIdentity, error) {
+	return a.server.resolveConnectionIdentity(r), nil
+}
+
+// headerAuthenticator trusts a request header set by an upstream
+// reverse proxy or SSO gateway, per --auth-header. If
+// AuthHeaderTrustSentinel is set, the header is only trusted when that
+// sentinel header is also present, so gotty refuses to honor
+// AuthHeader on requests that didn't actually traverse the proxy (e.g.
+// an attacker hitting gotty directly and setting the header itself).
+type headerAuthenticator struct {
+	server *Server
+}
+
+func (a headerAuthenticator) authenticate(r *http.Request) (*connectionIdentity, error) {
+	if sentinel := a.server.options.AuthHeaderTrustSentinel; sentinel != "" {
+		if r.Header.Get(sentinel) == "" {
+			return nil, nil
+		}
+	}
+
+	user := r.Header.Get(a.server.options.AuthHeader)
+	if user == "" {
+		return nil, nil
+	}
+
+	return &connectionIdentity{
+		User: user,
+		Params: map[string]string{
+			identityParamPrefix + "USER": user,
+		},
+	}, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderAuthenticatorRequiresSentinelWhenConfigured(t *testing.T) {
+	server := &Server{options: &Options{
+		AuthHeader:              "X-Remote-User",
+		AuthHeaderTrustSentinel: "X-Proxy-Verified",
+	}}
+	auth := headerAuthenticator{server: server}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("X-Remote-User", "alice")
+	// Deliberately not setting the sentinel header: a request that
+	// reached gotty directly, spoofing X-Remote-User itself, must not
+	// be trusted just because the header name matches.
+
+	identity, err := auth.authenticate(r)
+	if err != nil {
+		t.Fatalf("authenticate returned error: %s", err)
+	}
+	if identity != nil {
+		t.Fatalf("expected no identity without the trust sentinel, got %+v", identity)
+	}
+}
+
+func TestHeaderAuthenticatorTrustsHeaderOnceSentinelPresent(t *testing.T) {
+	server := &Server{options: &Options{
+		AuthHeader:              "X-Remote-User",
+		AuthHeaderTrustSentinel: "X-Proxy-Verified",
+	}}
+	auth := headerAuthenticator{server: server}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("X-Remote-User", "alice")
+	r.Header.Set("X-Proxy-Verified", "1")
+
+	identity, err := auth.authenticate(r)
+	if err != nil {
+		t.Fatalf("authenticate returned error: %s", err)
+	}
+	if identity == nil || identity.User != "alice" {
+		t.Fatalf("expected identity for alice, got %+v", identity)
+	}
+}
+
+func TestHeaderAuthenticatorWithoutSentinelConfigured(t *testing.T) {
+	server := &Server{options: &Options{
+		AuthHeader: "X-Remote-User",
+	}}
+	auth := headerAuthenticator{server: server}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("X-Remote-User", "alice")
+
+	identity, err := auth.authenticate(r)
+	if err != nil {
+		t.Fatalf("authenticate returned error: %s", err)
+	}
+	if identity == nil || identity.User != "alice" {
+		t.Fatalf("expected identity for alice, got %+v", identity)
+	}
+}
+
+func TestHeaderAuthenticatorMissingHeaderFallsThrough(t *testing.T) {
+	server := &Server{options: &Options{
+		AuthHeader: "X-Remote-User",
+	}}
+	auth := headerAuthenticator{server: server}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+
+	identity, err := auth.authenticate(r)
+	if err != nil {
+		t.Fatalf("authenticate returned error: %s", err)
+	}
+	if identity != nil {
+		t.Fatalf("expected no identity for a request with no header set, got %+v", identity)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// isDraining reports whether the server has started shutting down.
+// /healthz flips to unhealthy the instant this becomes true so upstream
+// load balancers stop routing new traffic before the drain timeout even
+// starts ticking.
+func (server *Server) isDraining() bool {
+	return atomic.LoadInt32(&server.draining) == 1
+}
+
+// drain replaces the old "cancel the root context and call srv.Close()"
+// shutdown, which yanked every live PTY WebSocket instantly. It stops
+// accepting new connections, logs a warning for whoever is still
+// connected (see broadcastDrainWarning's note on why that isn't yet a
+// banner the client itself sees), and gives existing sessions up to
+// DrainTimeout seconds to finish on their own before forcing
+// srv.Close().
+func (server *Server) drain(srv *http.Server) {
+	if !atomic.CompareAndSwapInt32(&server.draining, 0, 1) {
+		return
+	}
+
+	if server.options.EnableMux {
+		// In EnableMux mode srv serves at most one of the two
+		// sub-listeners multiplexed by muxCMux (see mux.go); closing the shared root
+		// listener right away stops new connections of every kind
+		// (plain HTTP, HTTP/2, and the admin gRPC service) from being
+		// accepted, without waiting for the drain timeout.
+		if server.muxListener != nil {
+			server.muxListener.Close()
+		}
+	} else {
+		// Otherwise srv owns its listener; Shutdown closes it at once.
+		// It does not wait for hijacked WebSocket connections, which are
+		// left to the wait below.
+		go srv.Shutdown(context.Background())
+	}
+
+	timeout := time.Duration(server.options.DrainTimeout) * time.Second
+	if timeout <= 0 {
+		server.closeServers(srv)
+		return
+	}
+
+	log.Printf("Draining: waiting up to %s for active sessions to close", timeout)
+	server.broadcastDrainWarning(fmt.Sprintf("session ending in %s", timeout))
+
+	done := make(chan struct{})
+	go func() {
+		server.wsWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("Drain complete, all sessions closed")
+	case <-time.After(timeout):
+		log.Printf("Drain timeout reached, forcing close of remaining connection(s)")
+	}
+
+	server.closeServers(srv)
+}
+
+// closeServers cancels every live session (srv.Close does not reach
+// hijacked WebSocket connections) and force-closes srv and, in EnableMux
+// mode, the admin gRPC server and the plain-HTTP/h2c server that also
+// live behind the shared listener. Without this, enabling mux silently
+// broke drain: those two
+// servers are local to serve() and unreachable any other way, so they'd
+// keep serving already-accepted connections (and, worse, the admin API
+// would keep handling new ones arriving on its own matched listener)
+// past the point drain is supposed to have ended.
+func (server *Server) closeServers(srv *http.Server) {
+	server.sessions.Range(func(_, value interface{}) bool {
+		value.(*session).cancel()
+		return true
+	})
+	srv.Close()
+	if !server.options.EnableMux {
+		return
+	}
+	if server.grpcServer != nil {
+		server.grpcServer.GracefulStop()
+	}
+	if server.h2cServer != nil {
+		server.h2cServer.Close()
+	}
+}
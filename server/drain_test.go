@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestDrainServer(t *testing.T) (*Server, *http.Server) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	srv := &http.Server{Handler: http.NewServeMux()}
+	go srv.Serve(listener)
+
+	server := &Server{options: &Options{DrainTimeout: 0}}
+	return server, srv
+}
+
+func TestIsDrainingBeforeAndAfterDrain(t *testing.T) {
+	server, srv := newTestDrainServer(t)
+
+	if server.isDraining() {
+		t.Fatalf("expected isDraining to be false before drain is called")
+	}
+
+	server.drain(srv)
+
+	if !server.isDraining() {
+		t.Fatalf("expected isDraining to be true after drain is called")
+	}
+}
+
+func TestDrainIsIdempotent(t *testing.T) {
+	server, srv := newTestDrainServer(t)
+
+	// A second call must not panic (e.g. by double-closing a listener)
+	// and must not block.
+	done := make(chan struct{})
+	go func() {
+		server.drain(srv)
+		server.drain(srv)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("drain did not return; a second call likely blocked or deadlocked")
+	}
+}
+
+func TestDrainClosesMuxListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	srv := &http.Server{Handler: http.NewServeMux()}
+
+	server := &Server{
+		options:     &Options{EnableMux: true, DrainTimeout: 0},
+		muxListener: listener,
+	}
+
+	server.drain(srv)
+
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Fatalf("expected the mux listener to be closed and refusing new connections after drain")
+	}
+}
+
+func TestBroadcastDrainWarningDeliversToRegisteredSessions(t *testing.T) {
+	server := &Server{}
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	warnings := server.registerSession("127.0.0.1:9999", "alice", cancel)
+
+	server.broadcastDrainWarning("session ending soon")
+
+	select {
+	case msg := <-warnings:
+		if msg != "session ending soon" {
+			t.Fatalf("expected the broadcast message, got %q", msg)
+		}
+	default:
+		t.Fatalf("expected the registered session's warnings channel to receive the broadcast")
+	}
+}
+
+func TestBroadcastDrainWarningDoesNotBlockOnFullChannel(t *testing.T) {
+	server := &Server{}
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	warnings := server.registerSession("127.0.0.1:9999", "alice", cancel)
+
+	// Fill the (buffered, size 1) channel, then broadcast again: this
+	// must not block the caller even though nothing is draining it.
+	server.broadcastDrainWarning("first")
+	done := make(chan struct{})
+	go func() {
+		server.broadcastDrainWarning("second")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("broadcastDrainWarning blocked on a full channel instead of dropping the message")
+	}
+
+	<-warnings // drain the one message that did make it through
+}
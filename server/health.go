@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// healthStatus is the JSON body returned by /healthz and /readyz.
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// handleHealthz is the liveness endpoint: it confirms the server's
+// goroutines are alive and able to answer HTTP requests, and reports
+// unhealthy (503) once a drain has started. It checks no dependency, so
+// a deadlocked dependency can never make it report healthy by accident.
+// Readiness, which also reflects TLS material, connections, and backend
+// health, is handled separately by handleReadyz.
+func (server *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ok", Checks: map[string]string{"alive": "ok"}}
+	if server.isDraining() {
+		status.Status = "unhealthy"
+		status.Checks["alive"] = "draining"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleReadyz is the readiness endpoint: it reports a composite of
+// every condition that needs to hold for this instance to usefully take
+// traffic right now, so it can be wired into a Kubernetes readiness
+// probe or a traditional load balancer's health check. Any failing
+// check fails the whole probe with a 503, even though the server
+// process itself is alive.
+func (server *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ok", Checks: map[string]string{}}
+
+	checks := []struct {
+		name string
+		err  error
+	}{
+		{"tls", server.tlsHealthy()},
+		{"connections", server.connectionsHealthy()},
+		{"factory", server.factory.HealthCheck()},
+		{"drain", server.drainHealthy()},
+	}
+
+	for _, check := range checks {
+		if check.err != nil {
+			status.Status = "unhealthy"
+			status.Checks[check.name] = check.err.Error()
+		} else {
+			status.Checks[check.name] = "ok"
+		}
+	}
+
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// connectionsHealthy reports an error once the server is at or above
+// MaxConnections, so a load balancer stops sending it more traffic
+// before generateHandleWS has to start rejecting connections outright.
+func (server *Server) connectionsHealthy() error {
+	if server.options.MaxConnections <= 0 {
+		return nil
+	}
+	if atomic.LoadInt64(server.connections) >= int64(server.options.MaxConnections) {
+		return errors.Errorf("at MaxConnections (%d)", server.options.MaxConnections)
+	}
+	return nil
+}
+
+// drainHealthy reports an error once a drain has started.
+func (server *Server) drainHealthy() error {
+	if server.isDraining() {
+		return errors.New("draining")
+	}
+	return nil
+}
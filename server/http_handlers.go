@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/yudai/gotty/webtty"
+)
+
+// generateHandleWS returns the handler for the WebSocket endpoint that
+// drives a single PTY session. It enforces the Once option, then runs
+// the configured auth chain (client certificate, forwarded header, or
+// OIDC bearer token — see auth.go) to resolve the caller's identity and
+// hands it to the backend factory along with the request's params.
+func (server *Server) generateHandleWS(ctx context.Context, cancel context.CancelFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr := r.RemoteAddr
+
+		if server.options.Once {
+			if !atomic.CompareAndSwapInt64(server.once, 0, 1) {
+				http.Error(w, "Server is already used by one client", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		num := atomic.AddInt64(server.connections, 1)
+		closeReason := "unknown reason"
+		defer func() {
+			atomic.AddInt64(server.connections, -1)
+			log.Printf("Connection closed by %s: %s", remoteAddr, closeReason)
+		}()
+
+		if server.options.MaxConnections > 0 && num > int64(server.options.MaxConnections) {
+			http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+			closeReason = "exceeding MaxConnections"
+			return
+		}
+
+		identity, err := server.resolveIdentity(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			closeReason = err.Error()
+			return
+		}
+		if identity == nil && len(server.authenticators()) > 0 {
+			// At least one auth mode is configured but none of them
+			// resolved an identity: unlike certAuthenticator, which is
+			// backstopped by tls.RequireAndVerifyClientCert at the TLS
+			// layer, the header and OIDC modes have no such backstop of
+			// their own, so an absent credential must fail closed here
+			// rather than fall through as an anonymous session.
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			closeReason = "no auth mode resolved an identity"
+			return
+		}
+		if identity != nil {
+			log.Printf("New client connected: %s (user: %s)", remoteAddr, identity.User)
+		} else {
+			log.Printf("New client connected: %s", remoteAddr)
+		}
+
+		conn, err := server.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Print("Failed to upgrade connection: " + err.Error())
+			return
+		}
+		defer conn.Close()
+
+		server.wsWG.Add(1)
+		defer server.wsWG.Done()
+
+		sessionCtx, sessionCancel := context.WithCancel(ctx)
+		user := ""
+		if identity != nil {
+			user = identity.User
+		}
+		warnings := server.registerSession(remoteAddr, user, sessionCancel)
+		defer server.unregisterSession(remoteAddr)
+
+		// This is the hook point for a real drain-time banner, but it
+		// does not reach the client yet: webtty has no API to inject an
+		// out-of-band message into a running PTY session, so today this
+		// only logs server-side. See broadcastDrainWarning's doc comment
+		// in server.go.
+		go func() {
+			for message := range warnings {
+				log.Printf("Warning client %s: %s", remoteAddr, message)
+			}
+		}()
+
+		err = server.processWSConn(sessionCtx, conn, identity)
+		switch {
+		case err == nil:
+			closeReason = "closed"
+		case errors.Is(err, context.Canceled):
+			// sessionCtx is canceled by KillSession (admin.go) or by a
+			// drain (drain.go); processWSConn's error in that case is
+			// whatever wraps ctx.Err(), not necessarily sessionCtx.Err()
+			// itself, so compare against context.Canceled rather than
+			// requiring an exact match.
+			closeReason = "context canceled"
+		default:
+			closeReason = err.Error()
+		}
+	}
+}
+
+// processWSConn performs the webtty handshake on conn and runs the PTY
+// session to completion. identity, when non-nil, is merged into the
+// params handed to the backend factory so the spawned process can see
+// GOTTY_USER and the other GOTTY_CERT_* values.
+func (server *Server) processWSConn(ctx context.Context, conn *websocket.Conn, identity *connectionIdentity) error {
+	typ, initLine, err := conn.ReadMessage()
+	if err != nil {
+		return errors.Wrapf(err, "failed to authenticate websocket connection")
+	}
+	if typ != websocket.TextMessage {
+		return errors.New("failed to authenticate websocket connection: invalid message type")
+	}
+
+	var init webtty.InitMessage
+	if err := json.Unmarshal(initLine, &init); err != nil {
+		return errors.Wrapf(err, "failed to parse init message")
+	}
+
+	query, err := url.ParseQuery(init.Arguments)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse arguments")
+	}
+
+	params := map[string]string{}
+	for key, values := range query {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	if identity != nil {
+		for key, value := range identity.Params {
+			params[key] = value
+		}
+	}
+
+	slave, err := server.factory.New(params)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create backend")
+	}
+	defer slave.Close()
+
+	tty, err := webtty.New(conn, slave)
+	if err != nil {
+		return errors.Wrapf(err, "failed to initialize webtty")
+	}
+
+	return tty.Run(ctx)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// identityParamPrefix is prepended to every identity value placed in a
+// connection's params map, mirroring the GOTTY_ prefix already used for
+// other request-scoped values passed to the backend (e.g. GOTTY_USER).
+const identityParamPrefix = "GOTTY_"
+
+// connectionIdentity holds everything the server was able to verify
+// about the caller of a single WebSocket connection. It is built from
+// the client certificate presented during the TLS handshake when
+// EnableTLSClientAuth is on, or by the forwarded-header and OIDC auth
+// modes (see the auth chain in auth.go), which set only User and
+// GOTTY_USER.
+type connectionIdentity struct {
+	// User is the resolved identity to expose to the backend as
+	// GOTTY_USER. It defaults to the certificate's CommonName.
+	User string
+
+	// Params holds every individual field the server extracted,
+	// keyed by GOTTY_-prefixed name (GOTTY_CERT_CN, GOTTY_CERT_EMAIL,
+	// GOTTY_CERT_SAN, and one GOTTY_CERT_OID_<oid> entry per OID
+	// configured via TLSUserOIDs).
+	Params map[string]string
+
+	// Command is the rendered result of TLSUserCommandTemplate, if one
+	// is configured: a shell command or argument built from this
+	// identity for command templates that need to chain through
+	// su/sudo, e.g. "sudo -u {{.User}} -i". It is also exposed to the
+	// backend as GOTTY_CERT_COMMAND. Empty when no template is set.
+	Command string
+}
+
+// resolveConnectionIdentity inspects the verified peer certificate of r,
+// if any, and extracts the Subject fields and configured OID extensions
+// requested by the server's TLS options. It returns nil if client-cert
+// auth isn't enabled or the request carries no verified certificate.
+func (server *Server) resolveConnectionIdentity(r *http.Request) *connectionIdentity {
+	if !server.options.EnableTLSClientAuth || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := r.TLS.PeerCertificates[0]
+
+	identity := &connectionIdentity{
+		Params: map[string]string{},
+	}
+
+	identity.Params[identityParamPrefix+"CERT_CN"] = cert.Subject.CommonName
+	identity.User = cert.Subject.CommonName
+
+	if emails := subjectEmailAddresses(cert.Subject); len(emails) > 0 {
+		identity.Params[identityParamPrefix+"CERT_EMAIL"] = emails[0]
+	}
+	if len(cert.EmailAddresses) > 0 {
+		identity.Params[identityParamPrefix+"CERT_EMAIL"] = cert.EmailAddresses[0]
+	}
+	if len(cert.DNSNames) > 0 {
+		identity.Params[identityParamPrefix+"CERT_SAN"] = cert.DNSNames[0]
+	}
+
+	for _, oid := range server.options.TLSUserOIDs {
+		id, err := parseOID(oid)
+		if err != nil {
+			continue
+		}
+		if value, ok := subjectOIDValue(cert.Subject, id); ok {
+			identity.Params[identityParamPrefix+"CERT_OID_"+oid] = value
+		}
+	}
+
+	if server.options.TLSUserHeader != "" {
+		if value, ok := identity.Params[identityParamPrefix+server.options.TLSUserHeader]; ok {
+			identity.User = value
+		}
+	}
+
+	identity.Params[identityParamPrefix+"USER"] = identity.User
+
+	if command, err := server.renderIdentityCommand(identity); err != nil {
+		log.Printf("Failed to render --tls-user-command-template: %s", err)
+	} else if command != "" {
+		identity.Command = command
+		identity.Params[identityParamPrefix+"CERT_COMMAND"] = command
+	}
+
+	return identity
+}
+
+// renderIdentityCommand executes TLSUserCommandTemplate, if configured,
+// against identity. The template sees identity's exported fields
+// directly (e.g. {{.User}}) and every extracted field via Params (e.g.
+// {{index .Params "GOTTY_CERT_CN"}}), so an operator can map a verified
+// certificate to a shell command argument or an environment variable
+// for su/sudo chaining without gotty needing to know about any
+// particular scheme.
+func (server *Server) renderIdentityCommand(identity *connectionIdentity) (string, error) {
+	if server.options.TLSUserCommandTemplate == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New("tls-user-command").Parse(server.options.TLSUserCommandTemplate)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid --tls-user-command-template")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, identity); err != nil {
+		return "", errors.Wrapf(err, "failed to execute --tls-user-command-template")
+	}
+	return buf.String(), nil
+}
+
+// subjectEmailAddresses returns the legacy PKCS#9 emailAddress RDN
+// values, which some CAs still place in the Subject rather than in the
+// certificate's SAN extension.
+func subjectEmailAddresses(subject pkix.Name) []string {
+	var emailOID = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+	var emails []string
+	for _, name := range subject.Names {
+		if name.Type.Equal(emailOID) {
+			if s, ok := name.Value.(string); ok {
+				emails = append(emails, s)
+			}
+		}
+	}
+	return emails
+}
+
+// subjectOIDValue returns the string value of the first Subject RDN
+// matching oid, if any.
+func subjectOIDValue(subject pkix.Name, oid asn1.ObjectIdentifier) (string, bool) {
+	for _, name := range subject.Names {
+		if name.Type.Equal(oid) {
+			if s, ok := name.Value.(string); ok {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseOID parses a dotted-decimal OID string such as "2.5.4.3".
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid OID %q", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"testing"
+)
+
+func selfSignedTestCert(t *testing.T, subject pkix.Name, emails []string, dnsNames []string) *x509.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        subject,
+		EmailAddresses: emails,
+		DNSNames:       dnsNames,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	return cert
+}
+
+func requestWithPeerCert(cert *x509.Certificate) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	return r
+}
+
+func TestResolveConnectionIdentityDisabledWithoutClientAuth(t *testing.T) {
+	server := &Server{options: &Options{}}
+	cert := selfSignedTestCert(t, pkix.Name{CommonName: "alice"}, nil, nil)
+
+	identity := server.resolveConnectionIdentity(requestWithPeerCert(cert))
+	if identity != nil {
+		t.Fatalf("expected no identity when EnableTLSClientAuth is off, got %+v", identity)
+	}
+}
+
+func TestResolveConnectionIdentityExtractsCommonNameAndSAN(t *testing.T) {
+	server := &Server{options: &Options{EnableTLSClientAuth: true}}
+	cert := selfSignedTestCert(t, pkix.Name{CommonName: "alice"}, []string{"alice@example.com"}, []string{"alice.example.com"})
+
+	identity := server.resolveConnectionIdentity(requestWithPeerCert(cert))
+	if identity == nil {
+		t.Fatalf("expected an identity for a verified client certificate")
+	}
+	if identity.User != "alice" {
+		t.Fatalf("expected User to default to CommonName, got %q", identity.User)
+	}
+	if identity.Params["GOTTY_CERT_CN"] != "alice" {
+		t.Fatalf("expected GOTTY_CERT_CN alice, got %q", identity.Params["GOTTY_CERT_CN"])
+	}
+	if identity.Params["GOTTY_CERT_EMAIL"] != "alice@example.com" {
+		t.Fatalf("expected GOTTY_CERT_EMAIL, got %q", identity.Params["GOTTY_CERT_EMAIL"])
+	}
+	if identity.Params["GOTTY_CERT_SAN"] != "alice.example.com" {
+		t.Fatalf("expected GOTTY_CERT_SAN, got %q", identity.Params["GOTTY_CERT_SAN"])
+	}
+}
+
+func TestResolveConnectionIdentityExtractsConfiguredOID(t *testing.T) {
+	// 2.5.4.11 is the standard OrganizationalUnit RDN OID.
+	ouOID := asn1.ObjectIdentifier{2, 5, 4, 11}
+	subject := pkix.Name{
+		CommonName: "alice",
+		ExtraNames: []pkix.AttributeTypeAndValue{
+			{Type: ouOID, Value: "engineering"},
+		},
+	}
+	server := &Server{options: &Options{EnableTLSClientAuth: true, TLSUserOIDs: []string{"2.5.4.11"}}}
+	cert := selfSignedTestCert(t, subject, nil, nil)
+
+	identity := server.resolveConnectionIdentity(requestWithPeerCert(cert))
+	if identity == nil {
+		t.Fatalf("expected an identity for a verified client certificate")
+	}
+	if got := identity.Params["GOTTY_CERT_OID_2.5.4.11"]; got != "engineering" {
+		t.Fatalf("expected the configured OID to be extracted, got %q", got)
+	}
+}
+
+func TestResolveConnectionIdentityTLSUserHeaderOverridesUser(t *testing.T) {
+	server := &Server{options: &Options{
+		EnableTLSClientAuth: true,
+		TLSUserHeader:       "CERT_EMAIL",
+	}}
+	cert := selfSignedTestCert(t, pkix.Name{CommonName: "alice"}, []string{"alice@example.com"}, nil)
+
+	identity := server.resolveConnectionIdentity(requestWithPeerCert(cert))
+	if identity == nil {
+		t.Fatalf("expected an identity for a verified client certificate")
+	}
+	if identity.User != "alice@example.com" {
+		t.Fatalf("expected TLSUserHeader to override User with the email, got %q", identity.User)
+	}
+}
+
+func TestRenderIdentityCommandEmptyWithoutTemplate(t *testing.T) {
+	server := &Server{options: &Options{}}
+	identity := &connectionIdentity{User: "alice", Params: map[string]string{}}
+
+	command, err := server.renderIdentityCommand(identity)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if command != "" {
+		t.Fatalf("expected an empty command with no TLSUserCommandTemplate, got %q", command)
+	}
+}
+
+func TestRenderIdentityCommandRendersTemplate(t *testing.T) {
+	server := &Server{options: &Options{TLSUserCommandTemplate: "sudo -u {{.User}} -i"}}
+	identity := &connectionIdentity{User: "alice", Params: map[string]string{}}
+
+	command, err := server.renderIdentityCommand(identity)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if command != "sudo -u alice -i" {
+		t.Fatalf("expected rendered sudo command, got %q", command)
+	}
+}
+
+func TestParseOID(t *testing.T) {
+	oid, err := parseOID("2.5.4.3")
+	if err != nil {
+		t.Fatalf("expected a valid dotted-decimal OID to parse, got error: %s", err)
+	}
+	want := asn1.ObjectIdentifier{2, 5, 4, 3}
+	if !oid.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, oid)
+	}
+
+	if _, err := parseOID("not-an-oid"); err == nil {
+		t.Fatalf("expected an invalid OID string to fail to parse")
+	}
+}
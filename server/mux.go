@@ -0,0 +1,105 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/soheilhy/cmux"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// serve accepts connections on the address returned by server.URL() and
+// dispatches them to the HTTP/WebSocket handler and the admin gRPC
+// service over that single listener. Previously Run let
+// http.Server.ListenAndServe(TLS) own the listener outright; doing the
+// Listen ourselves lets cmux sniff each connection's first bytes (a gRPC
+// request's content-type, or anything else) and route it without a
+// second port or a reverse proxy in front of gotty.
+func (server *Server) serve(srv *http.Server) error {
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", srv.Addr)
+	}
+	server.muxListener = listener
+
+	if server.options.EnableTLS {
+		listener = tls.NewListener(listener, srv.TLSConfig)
+	}
+
+	mux := cmux.New(listener)
+	server.muxCMux = mux
+
+	// gRPC always speaks HTTP/2 with a content-type of
+	// application/grpc; match on that before falling through to
+	// everything else.
+	grpcL := mux.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+	httpL := mux.Match(cmux.Any())
+
+	httpSrv := server.httpServerFor(srv)
+
+	grpcServer := server.newAdminServer()
+	server.grpcServer = grpcServer
+
+	// drain (see drain.go) needs to stop both of these explicitly:
+	// neither is reachable through srv once this function returns, so
+	// srv.Close() alone would leave the admin gRPC service (and, in
+	// non-TLS mode, all HTTP traffic, since it's served by a distinct
+	// *http.Server there — see httpServerFor) being accepted after a
+	// drain starts.
+	errCh := make(chan error, 3)
+	go func() { errCh <- grpcServer.Serve(grpcL) }()
+	go func() { errCh <- httpSrv.Serve(httpL) }()
+	go func() { errCh <- mux.Serve() }()
+
+	err = <-errCh
+	if server.isDraining() {
+		// drain closed the shared listener on purpose; report it the way
+		// http.Server does so Run does not cancel the live sessions.
+		return http.ErrServerClosed
+	}
+	return err
+}
+
+// httpServerFor returns the *http.Server that should serve httpL, the
+// cmux branch carrying everything but the admin gRPC service.
+//
+// With TLS, the client negotiates h2 vs HTTP/1.1 over ALPN during the
+// handshake, and srv (configured for h2 here via http2.ConfigureServer)
+// already dispatches on that through its TLSNextProto map, so srv
+// itself is enough.
+//
+// Without TLS there's no ALPN to negotiate h2 from. An h2c ("HTTP/2
+// with prior knowledge") client sends the literal HTTP/2 connection
+// preface over the plain TCP connection — the same bytes cmux.HTTP2()
+// matches — so routing it to a second listener and serving that with
+// bare srv doesn't work: srv only speaks h2 following a TLS ALPN
+// handshake, and parses the preface as a malformed HTTP/1.1 request
+// line instead. h2c.NewHandler recognizes the preface itself and falls
+// back to srv.Handler as plain HTTP/1.1 otherwise, so one listener and
+// one handler cover both without cmux needing to tell them apart.
+func (server *Server) httpServerFor(srv *http.Server) *http.Server {
+	if server.options.EnableTLS {
+		http2.ConfigureServer(srv, nil)
+		return srv
+	}
+
+	h2cServer := &http.Server{Addr: srv.Addr, Handler: h2c.NewHandler(srv.Handler, &http2.Server{})}
+	server.h2cServer = h2cServer
+	return h2cServer
+}
+
+// newAdminServer builds the gRPC server backing the session/admin
+// control plane (list sessions, kill a session, trigger a TLS reload).
+// The service implementation lives in admin.go.
+func (server *Server) newAdminServer() *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.ForceServerCodec(jsonCodec{}),
+		grpc.UnaryInterceptor(server.requireAdminToken),
+	)
+	RegisterAdminServer(grpcServer, &adminService{server: server})
+	return grpcServer
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHTTPServerForWithTLSReturnsSrvItself(t *testing.T) {
+	server := &Server{options: &Options{EnableTLS: true}}
+	srv := &http.Server{Addr: ":0", Handler: http.NewServeMux()}
+
+	got := server.httpServerFor(srv)
+
+	if got != srv {
+		t.Fatalf("expected TLS mode to reuse srv itself (ALPN already dispatches h2), got a distinct *http.Server")
+	}
+	if server.h2cServer != nil {
+		t.Fatalf("expected no h2cServer to be set in TLS mode")
+	}
+}
+
+func TestHTTPServerForWithoutTLSWrapsHandlerForH2C(t *testing.T) {
+	server := &Server{options: &Options{EnableTLS: false}}
+	srv := &http.Server{Addr: ":0", Handler: http.NewServeMux()}
+
+	got := server.httpServerFor(srv)
+
+	if got == srv {
+		t.Fatalf("expected non-TLS mode to serve through a distinct h2c-wrapped server, not bare srv")
+	}
+	if server.h2cServer != got {
+		t.Fatalf("expected the returned server to be recorded on server.h2cServer for drain to close later")
+	}
+	// h2c.NewHandler's return type is unexported, so the best black-box
+	// check available is that it's a distinct handler wrapping srv's,
+	// not srv.Handler itself passed through unwrapped.
+	if got.Handler == srv.Handler {
+		t.Fatalf("expected the h2c handler to wrap srv.Handler, not reuse it directly")
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// oidcJWKSRefreshInterval bounds how often a single oidcAuth refetches
+// its JWKS document. A compromised or rotated signing key still takes
+// effect within this window even if the IdP never pings gotty.
+const oidcJWKSRefreshInterval = 5 * time.Minute
+
+// oidcJWKSMinRefetchInterval rate-limits refreshes triggered by an
+// unrecognized kid, since kid is attacker-controlled JWT header content
+// and would otherwise force a synchronous fetch on every request that
+// forges an unknown one.
+const oidcJWKSMinRefetchInterval = 30 * time.Second
+
+// oidcHTTPClient bounds how long a slow or hung JWKS endpoint can stall
+// a key lookup.
+var oidcHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// oidcAuth validates `Authorization: Bearer` JWTs against a JWKS
+// endpoint for --auth-oidc-jwks mode. It fetches and caches the JWKS
+// document, refreshing it periodically so key rotation on the IdP side
+// doesn't require restarting gotty.
+type oidcAuth struct {
+	server *Server
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	lastFetched time.Time
+}
+
+// oidcAuthenticator returns the server's cached oidcAuth, or starts a
+// fresh one the first time --auth-oidc-jwks is used.
+func (server *Server) oidcAuthenticator() *oidcAuth {
+	value := server.oidc.Load()
+	if value != nil {
+		return value.(*oidcAuth)
+	}
+	auth := &oidcAuth{server: server}
+	server.oidc.Store(auth)
+	return auth
+}
+
+func (a *oidcAuth) authenticate(r *http.Request) (*connectionIdentity, error) {
+	bearer := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(bearer, prefix) {
+		return nil, nil
+	}
+	token := strings.TrimPrefix(bearer, prefix)
+
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rejected OIDC bearer token")
+	}
+
+	claimName := a.server.options.AuthOIDCUserClaim
+	if claimName == "" {
+		claimName = "sub"
+	}
+	user, _ := claims[claimName].(string)
+	if user == "" {
+		return nil, errors.Errorf("OIDC token has no %q claim", claimName)
+	}
+
+	return &connectionIdentity{
+		User: user,
+		Params: map[string]string{
+			identityParamPrefix + "USER": user,
+		},
+	}, nil
+}
+
+// verify checks the token's RS256 signature against the cached JWKS
+// keys and validates iss, aud, and exp.
+func (a *oidcAuth) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+
+	header, err := decodeJWTSegment(parts[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode JWT header")
+	}
+	claims, err := decodeJWTSegment(parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode JWT claims")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode JWT signature")
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg != "RS256" {
+		return nil, errors.Errorf("unsupported JWT signing algorithm %q", alg)
+	}
+
+	kid, _ := header["kid"].(string)
+	key, err := a.publicKey(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.Wrapf(err, "JWT signature verification failed")
+	}
+
+	if iss, ok := claims["iss"].(string); a.server.options.AuthOIDCIssuer != "" && (!ok || iss != a.server.options.AuthOIDCIssuer) {
+		return nil, errors.Errorf("unexpected issuer %q", claims["iss"])
+	}
+	if a.server.options.AuthOIDCAudience != "" && !audienceContains(claims["aud"], a.server.options.AuthOIDCAudience) {
+		return nil, errors.Errorf("unexpected audience %v", claims["aud"])
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		// Fail closed: a token without an exp claim must not be
+		// treated as never-expiring.
+		return nil, errors.New("token has no exp claim")
+	}
+	if time.Unix(int64(exp), 0).Before(time.Now()) {
+		return nil, errors.New("token expired")
+	}
+
+	return claims, nil
+}
+
+// publicKey returns the RSA key for kid, refreshing the cached JWKS
+// document from AuthOIDCJWKSURL if it's stale or the key is unknown.
+// The network fetch itself runs without a.mu held, so a slow or hung
+// JWKS endpoint stalls only the callers racing to refresh, not every
+// concurrent WebSocket connection attempt across the server.
+func (a *oidcAuth) publicKey(kid string) (*rsa.PublicKey, error) {
+	a.mu.Lock()
+	key, haveKey := a.keys[kid]
+	fresh := time.Since(a.lastFetched) < oidcJWKSRefreshInterval
+	recentlyFetched := time.Since(a.lastFetched) < oidcJWKSMinRefetchInterval
+	a.mu.Unlock()
+
+	if haveKey && fresh {
+		return key, nil
+	}
+	if !haveKey && recentlyFetched {
+		return nil, errors.Errorf("no JWKS key for kid %q", kid)
+	}
+
+	keys, err := fetchJWKS(a.server.options.AuthOIDCJWKSURL)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastFetched = time.Now()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to refresh JWKS")
+	}
+	a.keys = keys
+
+	key, haveKey = a.keys[kid]
+	if !haveKey {
+		return nil, errors.Errorf("no JWKS key for kid %q", kid)
+	}
+	return key, nil
+}
+
+type jwksDocument struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// fetchJWKS downloads and parses a JWKS document into RSA public keys
+// keyed by kid.
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, error) {
+	resp, err := oidcHTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc jwksDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse JWKS document")
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := rsaPublicKeyFromJWK(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func rsaPublicKeyFromJWK(n, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+
+	eInt := 0
+	for _, b := range eBytes {
+		eInt = eInt<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: eInt,
+	}, nil
+}
+
+func decodeJWTSegment(segment string) (map[string]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil, err
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func audienceContains(aud interface{}, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// signTestJWT builds a compact RS256 JWT from the given claims, signed
+// with key and tagged with kid, without going through a JWKS endpoint.
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": kid}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("failed to marshal header: %s", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %s", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// newTestOIDCAuth returns an oidcAuth whose key cache is pre-seeded with
+// pub under kid, so verify never needs to reach a real JWKS endpoint.
+func newTestOIDCAuth(kid string, pub *rsa.PublicKey) *oidcAuth {
+	return &oidcAuth{
+		server: &Server{options: &Options{
+			AuthOIDCIssuer:   "https://issuer.example.com",
+			AuthOIDCAudience: "gotty",
+		}},
+		keys:        map[string]*rsa.PublicKey{kid: pub},
+		lastFetched: time.Now(),
+	}
+}
+
+func TestOIDCVerifyAcceptsValidToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	auth := newTestOIDCAuth("key-1", &key.PublicKey)
+
+	token := signTestJWT(t, key, "key-1", map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": "gotty",
+		"sub": "alice",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	})
+
+	claims, err := auth.verify(token)
+	if err != nil {
+		t.Fatalf("expected valid token to verify, got error: %s", err)
+	}
+	if claims["sub"] != "alice" {
+		t.Fatalf("expected sub claim alice, got %v", claims["sub"])
+	}
+}
+
+func TestOIDCVerifyRejectsBadSignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	// auth only knows about key's public half; the token is signed
+	// with a different, unrelated key.
+	auth := newTestOIDCAuth("key-1", &key.PublicKey)
+
+	token := signTestJWT(t, otherKey, "key-1", map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": "gotty",
+		"sub": "alice",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	})
+
+	if _, err := auth.verify(token); err == nil {
+		t.Fatalf("expected a signature mismatch to be rejected")
+	}
+}
+
+func TestOIDCVerifyRejectsWrongIssuer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	auth := newTestOIDCAuth("key-1", &key.PublicKey)
+
+	token := signTestJWT(t, key, "key-1", map[string]interface{}{
+		"iss": "https://not-the-configured-issuer.example.com",
+		"aud": "gotty",
+		"sub": "alice",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	})
+
+	if _, err := auth.verify(token); err == nil {
+		t.Fatalf("expected a mismatched issuer to be rejected")
+	}
+}
+
+func TestOIDCVerifyRejectsWrongAudience(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	auth := newTestOIDCAuth("key-1", &key.PublicKey)
+
+	token := signTestJWT(t, key, "key-1", map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": "someone-else",
+		"sub": "alice",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	})
+
+	if _, err := auth.verify(token); err == nil {
+		t.Fatalf("expected a mismatched audience to be rejected")
+	}
+}
+
+func TestOIDCVerifyRejectsMissingExp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	auth := newTestOIDCAuth("key-1", &key.PublicKey)
+
+	// No exp claim at all: must fail closed rather than being treated
+	// as a token that never expires.
+	token := signTestJWT(t, key, "key-1", map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": "gotty",
+		"sub": "alice",
+	})
+
+	if _, err := auth.verify(token); err == nil {
+		t.Fatalf("expected a token with no exp claim to be rejected")
+	}
+}
+
+func TestOIDCVerifyRejectsExpiredToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	auth := newTestOIDCAuth("key-1", &key.PublicKey)
+
+	token := signTestJWT(t, key, "key-1", map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": "gotty",
+		"sub": "alice",
+		"exp": float64(time.Now().Add(-time.Hour).Unix()),
+	})
+
+	if _, err := auth.verify(token); err == nil {
+		t.Fatalf("expected an expired token to be rejected")
+	}
+}
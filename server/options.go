@@ -0,0 +1,59 @@
+package server
+
+// Options configures a Server. Each exported field corresponds 1:1 to a
+// CLI flag (and config-file key) generated from its struct tags by
+// app.GenerateFlags: flagName/flagSName set the flag's long/short name,
+// flagDescribe its help text, and default the generated flag's default
+// value. app.ApplyFlags copies only flags that were set, so a field whose
+// flag isn't set keeps whatever value it already held. A field tagged `flagName:"-"` is internal and has no flag of its
+// own. Fields are grouped below by the feature that introduced them.
+type Options struct {
+	Address string `flagName:"address" flagSName:"a" flagDescribe:"IP address to listen" default:"0.0.0.0"`
+	Port    string `flagName:"port" flagSName:"p" flagDescribe:"Port number to listen" default:"8080"`
+
+	PermitWrite bool `flagName:"permit-write" flagSName:"w" flagDescribe:"Permit clients to write to the TTY (BE CAREFUL)" default:"false"`
+	Once        bool `flagName:"once" flagSName:"o" flagDescribe:"Accept only one client and exit on disconnection" default:"false"`
+	Timeout     int  `flagName:"timeout" flagDescribe:"Timeout seconds for waiting a client (0 to disable)" default:"0"`
+
+	IndexFile string `flagName:"index" flagDescribe:"Custom index.html file" default:""`
+
+	EnableRandomUrl bool `flagName:"random-url" flagSName:"r" flagDescribe:"Add a random string to the URL" default:"false"`
+	RandomUrlLength int  `flagName:"random-url-length" flagDescribe:"Random URL length" default:"8"`
+
+	// EnableBasicAuth is derived from Credential rather than set by its
+	// own flag; see app.ApplyFlags.
+	EnableBasicAuth bool   `flagName:"-"`
+	Credential      string `flagName:"credential" flagSName:"c" flagDescribe:"Credential for Basic Authentication (ex: user:pass, default disabled)" default:""`
+
+	// TLS hot-reload (see tls_reload.go).
+	EnableTLS           bool   `flagName:"tls" flagSName:"t" flagDescribe:"Enable TLS/SSL" default:"false"`
+	TLSCrtFile          string `flagName:"tls-crt" flagDescribe:"TLS/SSL certificate file path" default:"~/.gotty.crt"`
+	TLSKeyFile          string `flagName:"tls-key" flagDescribe:"TLS/SSL key file path" default:"~/.gotty.key"`
+	TLSCertReloadWatch  bool   `flagName:"tls-cert-reload-watch" flagDescribe:"Watch the TLS crt/key/CA files and reload them without restarting, in addition to reloading on SIGHUP" default:"false"`
+	EnableTLSClientAuth bool   `flagName:"tls-client-auth" flagDescribe:"Enable TLS/SSL client authentication" default:"false"`
+	TLSCACrtFile        string `flagName:"tls-ca-crt" flagDescribe:"Certificate authority crt file for client certifications" default:"~/.gotty.ca.crt"`
+
+	// Client-certificate identity (see identity.go).
+	TLSUserHeader          string   `flagName:"tls-user-header" flagDescribe:"Subject field to use as the client identity (CERT_CN, CERT_EMAIL, CERT_SAN, or CERT_OID_<oid>); defaults to the certificate's CommonName" default:""`
+	TLSUserOIDs            []string `flagName:"tls-user-oid" flagDescribe:"Subject OID (dotted-decimal, repeatable) to extract from the client certificate" default:""`
+	TLSUserCommandTemplate string   `flagName:"tls-user-command-template" flagDescribe:"text/template rendered from the verified client identity and exposed as GOTTY_CERT_COMMAND, e.g. for su/sudo command chaining" default:""`
+
+	// Connection multiplexing and the admin gRPC service (see mux.go,
+	// admin.go).
+	EnableMux  bool   `flagName:"mux" flagDescribe:"Multiplex WebSocket/HTTP traffic and the admin gRPC service on a single listener" default:"false"`
+	AdminToken string `flagName:"admin-token" flagDescribe:"Token required in the x-admin-token metadata of every admin gRPC call (--mux); the service rejects every call if unset" default:""`
+
+	// Graceful drain (see drain.go).
+	DrainTimeout int `flagName:"drain-timeout" flagDescribe:"Seconds to wait for active sessions to close on graceful shutdown before forcing them closed (0 to close immediately)" default:"60"`
+
+	// Health and readiness (see health.go).
+	MaxConnections int `flagName:"max-connections" flagDescribe:"Maximum number of simultaneous connections, enforced by /ws and reflected in /readyz (0 for unlimited)" default:"0"`
+
+	// Forwarded-header and OIDC bearer-token auth (see auth.go, oidc.go).
+	AuthHeader              string `flagName:"auth-header" flagDescribe:"Trust this request header, set by an upstream reverse proxy or SSO gateway, as the client's identity" default:""`
+	AuthHeaderTrustSentinel string `flagName:"auth-header-trust-sentinel" flagDescribe:"Only trust --auth-header when this header is also present, to confirm the request actually traversed the proxy" default:""`
+	AuthOIDCJWKSURL         string `flagName:"auth-oidc-jwks" flagDescribe:"JWKS URL to validate Authorization: Bearer JWTs against" default:""`
+	AuthOIDCUserClaim       string `flagName:"auth-oidc-user-claim" flagDescribe:"JWT claim to use as the client identity" default:"sub"`
+	AuthOIDCIssuer          string `flagName:"auth-oidc-issuer" flagDescribe:"Required iss claim value, if set" default:""`
+	AuthOIDCAudience        string `flagName:"auth-oidc-audience" flagDescribe:"Required aud claim value, if set" default:""`
+}
@@ -3,8 +3,6 @@ package server
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -16,6 +14,8 @@ import (
 	"github.com/elazarl/go-bindata-assetfs"
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
+	"github.com/soheilhy/cmux"
+	"google.golang.org/grpc"
 
 	"github.com/yudai/gotty/backend"
 	"github.com/yudai/gotty/pkg/homedir"
@@ -36,6 +36,90 @@ type Server struct {
 	url         *url.URL // use URL()
 	connections *int64   // Use atomic operations
 	once        *int64   // use atomic operations
+
+	tlsMaterial atomic.Value // holds *tlsMaterial, swapped on reload
+
+	sessions sync.Map // remoteAddr (string) -> *session, for the admin API
+
+	draining int32        // atomic: 1 once drain has started, see drain.go
+	oidc     atomic.Value // holds *oidcAuth, lazily created by oidcAuthenticator
+
+	// The following are only populated in EnableMux mode (see mux.go);
+	// drain needs direct references to them because, unlike srv, none
+	// of them is reachable once serve() returns.
+	muxListener net.Listener
+	muxCMux     cmux.CMux
+	h2cServer   *http.Server
+	grpcServer  *grpc.Server
+}
+
+// session is what the admin API (see admin.go) and drain (see drain.go)
+// need to know about one live PTY connection: enough to list it, tear
+// it down, and warn it before the server goes away.
+type session struct {
+	user     string
+	cancel   context.CancelFunc
+	warnings chan string // buffered; drain-time banners for this session
+}
+
+// registerSession records a newly-accepted connection so it shows up in
+// ListSessions, can be torn down by KillSession, and can be warned by a
+// drain. It returns the channel drain warnings for this session arrive
+// on.
+func (server *Server) registerSession(remoteAddr, user string, cancel context.CancelFunc) <-chan string {
+	warnings := make(chan string, 1)
+	server.sessions.Store(remoteAddr, &session{user: user, cancel: cancel, warnings: warnings})
+	return warnings
+}
+
+// broadcastDrainWarning queues message on every currently connected
+// session's warnings channel. Queuing is best-effort: a session whose
+// channel is still full from a previous broadcast simply misses this
+// one rather than blocking the drain. NOTE: today generateHandleWS's
+// consumer of this channel (see http_handlers.go) only logs message
+// server-side — webtty has no API yet to inject an out-of-band message
+// into a running PTY session, so this does not yet reach the client's
+// terminal. Treat it as ops-visible only until that hook exists.
+func (server *Server) broadcastDrainWarning(message string) {
+	server.sessions.Range(func(_, value interface{}) bool {
+		select {
+		case value.(*session).warnings <- message:
+		default:
+		}
+		return true
+	})
+}
+
+// unregisterSession removes a connection's bookkeeping once it closes.
+func (server *Server) unregisterSession(remoteAddr string) {
+	if value, ok := server.sessions.LoadAndDelete(remoteAddr); ok {
+		close(value.(*session).warnings)
+	}
+}
+
+// sessionSnapshot returns the currently live sessions for ListSessions.
+func (server *Server) sessionSnapshot() []SessionInfo {
+	var sessions []SessionInfo
+	server.sessions.Range(func(key, value interface{}) bool {
+		sessions = append(sessions, SessionInfo{
+			RemoteAddr: key.(string),
+			User:       value.(*session).user,
+		})
+		return true
+	})
+	return sessions
+}
+
+// killSession cancels the named session's context, which unwinds its
+// webtty.Run and closes the PTY. It reports whether a matching session
+// was found.
+func (server *Server) killSession(remoteAddr string) bool {
+	value, ok := server.sessions.Load(remoteAddr)
+	if !ok {
+		return false
+	}
+	value.(*session).cancel()
+	return true
 }
 
 func New(factory backend.Factory, options *Options) (*Server, error) {
@@ -90,15 +174,28 @@ func (server *Server) Run(ctx context.Context, options ...RunOption) error {
 		}()
 	}
 
+	if server.options.EnableTLS {
+		go server.watchTLSReload(cctx)
+	}
+
+	if server.options.EnableTLS {
+		log.Printf("TLS crt file: " + server.options.TLSCrtFile)
+		log.Printf("TLS key file: " + server.options.TLSKeyFile)
+	}
+
 	listenErr := make(chan error, 1)
 	go func() {
-		if server.options.EnableTLS {
-			crtFile := homedir.Expand(server.options.TLSCrtFile)
-			keyFile := homedir.Expand(server.options.TLSKeyFile)
-			log.Printf("TLS crt file: " + crtFile)
-			log.Printf("TLS key file: " + keyFile)
-
-			err = srv.ListenAndServeTLS(crtFile, keyFile)
+		if server.options.EnableMux {
+			// serve puts gotty's own Listen in front of srv so cmux can
+			// share the port between HTTP/WebSocket, HTTP/2, and the
+			// admin gRPC service; see mux.go.
+			err = server.serve(srv)
+		} else if server.options.EnableTLS {
+			// The certificate and key are served through srv.TLSConfig's
+			// GetCertificate callback so they can be rotated at runtime;
+			// passing empty paths here tells ListenAndServeTLS to rely on
+			// that callback instead of loading the files itself.
+			err = srv.ListenAndServeTLS("", "")
 		} else {
 			err = srv.ListenAndServe()
 		}
@@ -110,7 +207,7 @@ func (server *Server) Run(ctx context.Context, options ...RunOption) error {
 	go func() {
 		select {
 		case <-opts.gracefullCtx.Done():
-			srv.Shutdown(context.Background())
+			server.drain(srv)
 		case <-cctx.Done():
 		}
 	}()
@@ -123,7 +220,7 @@ func (server *Server) Run(ctx context.Context, options ...RunOption) error {
 			cancel()
 		}
 	case <-cctx.Done():
-		srv.Close()
+		server.drain(srv)
 		err = cctx.Err()
 	}
 
@@ -165,6 +262,8 @@ func (server *Server) setupHandlers(ctx context.Context, cancel context.CancelFu
 	wsMux := http.NewServeMux()
 	wsMux.Handle("/", siteHandler)
 	wsMux.HandleFunc(url.Path+"ws", server.generateHandleWS(ctx, cancel))
+	wsMux.HandleFunc("/healthz", server.handleHealthz)
+	wsMux.HandleFunc("/readyz", server.handleReadyz)
 	siteHandler = http.Handler(wsMux)
 
 	return server.wrapLogger(siteHandler)
@@ -179,7 +278,7 @@ func (server *Server) setupHTTPServer(handler http.Handler) (*http.Server, error
 		Handler: handler,
 	}
 
-	if server.options.EnableTLSClientAuth {
+	if server.options.EnableTLS {
 		tlsConfig, err := server.tlsConfig()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to setup TLS configuration")
@@ -206,19 +305,29 @@ func (server *Server) URL() *url.URL {
 	return server.url
 }
 
+// tlsConfig builds a *tls.Config that reads the server keypair and, when
+// client-cert auth is enabled, the CA pool from the atomically-swapped
+// tlsMaterial rather than baking them in at startup. This lets reloadTLS
+// rotate certificates on a running server without dropping connections.
 func (server *Server) tlsConfig() (*tls.Config, error) {
-	caFile := homedir.Expand(server.options.TLSCACrtFile)
-	caCert, err := ioutil.ReadFile(caFile)
-	if err != nil {
-		return nil, errors.New("Could not open CA crt file " + caFile)
-	}
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		return nil, errors.New("Could not parse CA crt file data in " + caFile)
+	if err := server.reloadTLS(); err != nil {
+		return nil, errors.Wrapf(err, "failed to load initial TLS material")
 	}
+
 	tlsConfig := &tls.Config{
-		ClientCAs:  caCertPool,
-		ClientAuth: tls.RequireAndVerifyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return server.currentTLSMaterial().cert, nil
+		},
 	}
+
+	if server.options.EnableTLSClientAuth {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			clientConfig := tlsConfig.Clone()
+			clientConfig.ClientCAs = server.currentTLSMaterial().caPool
+			return clientConfig, nil
+		}
+	}
+
 	return tlsConfig, nil
 }
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+
+	"github.com/yudai/gotty/pkg/homedir"
+)
+
+// tlsMaterial is the certificate and, when client-cert auth is enabled,
+// the CA pool backing the server's live tls.Config. A new tlsMaterial is
+// built from disk and atomically swapped into Server.tlsMaterial by
+// reloadTLS, so in-flight WebSocket connections keep using the
+// tls.Config they negotiated with while connections made after a reload
+// immediately see the new certificate and CA pool.
+type tlsMaterial struct {
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	notAfter time.Time // leaf certificate's expiry, for /readyz
+}
+
+// currentTLSMaterial returns the tlsMaterial in effect right now. It
+// must only be called after tlsConfig has performed the initial load.
+func (server *Server) currentTLSMaterial() *tlsMaterial {
+	return server.tlsMaterial.Load().(*tlsMaterial)
+}
+
+// tlsHealthy reports whether the currently-loaded TLS certificate is
+// present and not expired, for the /readyz composite check. It always
+// reports true when TLS isn't enabled.
+func (server *Server) tlsHealthy() error {
+	if !server.options.EnableTLS {
+		return nil
+	}
+	material, ok := server.tlsMaterial.Load().(*tlsMaterial)
+	if !ok || material.cert == nil {
+		return errors.New("no TLS certificate loaded")
+	}
+	if time.Now().After(material.notAfter) {
+		return errors.Errorf("TLS certificate expired at %s", material.notAfter)
+	}
+	return nil
+}
+
+// reloadTLS re-reads TLSCrtFile, TLSKeyFile, and, if client-cert auth is
+// enabled, TLSCACrtFile from disk and swaps them into server.tlsMaterial.
+// It is safe to call concurrently with in-flight TLS handshakes.
+func (server *Server) reloadTLS() error {
+	crtFile := homedir.Expand(server.options.TLSCrtFile)
+	keyFile := homedir.Expand(server.options.TLSKeyFile)
+	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load TLS keypair")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse TLS leaf certificate")
+	}
+	material := &tlsMaterial{cert: &cert, notAfter: leaf.NotAfter}
+
+	if server.options.EnableTLSClientAuth {
+		caFile := homedir.Expand(server.options.TLSCACrtFile)
+		caCert, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return errors.New("Could not open CA crt file " + caFile)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return errors.New("Could not parse CA crt file data in " + caFile)
+		}
+		material.caPool = caCertPool
+	}
+
+	server.tlsMaterial.Store(material)
+	log.Printf("Reloaded TLS certificate%s", map[bool]string{true: " and CA pool", false: ""}[server.options.EnableTLSClientAuth])
+	return nil
+}
+
+// watchTLSReload reloads the TLS material whenever the process receives
+// SIGHUP, and additionally whenever the configured certificate files
+// change on disk if TLSCertReloadWatch is set. It runs until ctx is
+// cancelled and should be started as its own goroutine.
+func (server *Server) watchTLSReload(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	var watchEvents <-chan fsnotify.Event
+	var watchedNames map[string]bool
+	if server.options.TLSCertReloadWatch {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Printf("Failed to start TLS file watcher: %s", err)
+		} else {
+			defer watcher.Close()
+			watchedDirs := map[string]bool{}
+			watchedNames = map[string]bool{}
+			for _, f := range []string{server.options.TLSCrtFile, server.options.TLSKeyFile, server.options.TLSCACrtFile} {
+				if f == "" {
+					continue
+				}
+				path := homedir.Expand(f)
+				watchedNames[path] = true
+
+				// Watch the containing directory rather than the file
+				// itself: inotify watches follow the inode, so the
+				// atomic rename-into-place pattern used by certbot,
+				// cert-manager, and acme.sh replaces the inode and
+				// leaves a file-level watch attached to the unlinked
+				// old one, silently going dark after the first
+				// rotation.
+				dir := filepath.Dir(path)
+				if watchedDirs[dir] {
+					continue
+				}
+				if err := watcher.Add(dir); err != nil {
+					log.Printf("Failed to watch %s for changes: %s", dir, err)
+					continue
+				}
+				watchedDirs[dir] = true
+			}
+			watchEvents = watcher.Events
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sigCh:
+			log.Printf("Received SIGHUP, reloading TLS material")
+			if err := server.reloadTLS(); err != nil {
+				log.Printf("Failed to reload TLS material: %s", err)
+			}
+
+		case event, ok := <-watchEvents:
+			if !ok {
+				watchEvents = nil
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if !watchedNames[filepath.Clean(event.Name)] {
+				// A directory watch sees every file in it; ignore
+				// anything other than the cert/key/CA files we care
+				// about.
+				continue
+			}
+			log.Printf("Detected change to %s, reloading TLS material", event.Name)
+			if err := server.reloadTLS(); err != nil {
+				log.Printf("Failed to reload TLS material: %s", err)
+			}
+		}
+	}
+}
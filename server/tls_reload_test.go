@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestKeyPair writes a self-signed cert/key PEM pair valid for the
+// given duration and returns their paths.
+func writeTestKeyPair(t *testing.T, dir, name string, notAfter time.Time) (crtPath, keyPath string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gotty-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+
+	crtPath = filepath.Join(dir, name+".crt")
+	keyPath = filepath.Join(dir, name+".key")
+
+	crtOut, err := os.Create(crtPath)
+	if err != nil {
+		t.Fatalf("failed to create %s: %s", crtPath, err)
+	}
+	defer crtOut.Close()
+	if err := pem.Encode(crtOut, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatalf("failed to write %s: %s", crtPath, err)
+	}
+
+	keyOut, err := os.Create(keyPath)
+	if err != nil {
+		t.Fatalf("failed to create %s: %s", keyPath, err)
+	}
+	defer keyOut.Close()
+	if err := pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}); err != nil {
+		t.Fatalf("failed to write %s: %s", keyPath, err)
+	}
+
+	return crtPath, keyPath
+}
+
+func TestReloadTLSLoadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	crtPath, keyPath := writeTestKeyPair(t, dir, "server", time.Now().Add(time.Hour))
+
+	server := &Server{options: &Options{
+		EnableTLS:  true,
+		TLSCrtFile: crtPath,
+		TLSKeyFile: keyPath,
+	}}
+
+	if err := server.reloadTLS(); err != nil {
+		t.Fatalf("expected reloadTLS to succeed, got error: %s", err)
+	}
+
+	material := server.currentTLSMaterial()
+	if material.cert == nil {
+		t.Fatalf("expected a loaded certificate")
+	}
+	if material.notAfter.IsZero() {
+		t.Fatalf("expected notAfter to be populated from the leaf certificate")
+	}
+}
+
+func TestReloadTLSPicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	crtPath, keyPath := writeTestKeyPair(t, dir, "server", time.Now().Add(time.Hour))
+
+	server := &Server{options: &Options{
+		EnableTLS:  true,
+		TLSCrtFile: crtPath,
+		TLSKeyFile: keyPath,
+	}}
+	if err := server.reloadTLS(); err != nil {
+		t.Fatalf("initial reloadTLS failed: %s", err)
+	}
+	firstNotAfter := server.currentTLSMaterial().notAfter
+
+	// Simulate rotation: overwrite the same paths with a cert that has a
+	// different expiry.
+	writeTestKeyPair(t, dir, "server", time.Now().Add(48*time.Hour))
+	if err := server.reloadTLS(); err != nil {
+		t.Fatalf("second reloadTLS failed: %s", err)
+	}
+
+	if server.currentTLSMaterial().notAfter.Equal(firstNotAfter) {
+		t.Fatalf("expected reloadTLS to pick up the rotated certificate's new expiry")
+	}
+}
+
+func TestReloadTLSFailsOnMissingFiles(t *testing.T) {
+	server := &Server{options: &Options{
+		EnableTLS:  true,
+		TLSCrtFile: "/nonexistent/does-not-exist.crt",
+		TLSKeyFile: "/nonexistent/does-not-exist.key",
+	}}
+
+	if err := server.reloadTLS(); err == nil {
+		t.Fatalf("expected reloadTLS to fail when the cert/key files don't exist")
+	}
+}
+
+func TestTLSHealthyOKWhenTLSDisabled(t *testing.T) {
+	server := &Server{options: &Options{EnableTLS: false}}
+	if err := server.tlsHealthy(); err != nil {
+		t.Fatalf("expected tlsHealthy to report ok when TLS isn't enabled, got: %s", err)
+	}
+}
+
+func TestTLSHealthyBeforeAnyLoad(t *testing.T) {
+	server := &Server{options: &Options{EnableTLS: true}}
+	if err := server.tlsHealthy(); err == nil {
+		t.Fatalf("expected tlsHealthy to fail before reloadTLS has ever populated tlsMaterial")
+	}
+}
+
+func TestTLSHealthyRejectsExpiredCertificate(t *testing.T) {
+	dir := t.TempDir()
+	crtPath, keyPath := writeTestKeyPair(t, dir, "server", time.Now().Add(-time.Hour))
+
+	server := &Server{options: &Options{
+		EnableTLS:  true,
+		TLSCrtFile: crtPath,
+		TLSKeyFile: keyPath,
+	}}
+	if err := server.reloadTLS(); err != nil {
+		t.Fatalf("reloadTLS failed: %s", err)
+	}
+
+	if err := server.tlsHealthy(); err == nil {
+		t.Fatalf("expected tlsHealthy to fail for an already-expired certificate")
+	}
+}
+
+func TestTLSHealthyAcceptsValidCertificate(t *testing.T) {
+	dir := t.TempDir()
+	crtPath, keyPath := writeTestKeyPair(t, dir, "server", time.Now().Add(time.Hour))
+
+	server := &Server{options: &Options{
+		EnableTLS:  true,
+		TLSCrtFile: crtPath,
+		TLSKeyFile: keyPath,
+	}}
+	if err := server.reloadTLS(); err != nil {
+		t.Fatalf("reloadTLS failed: %s", err)
+	}
+
+	if err := server.tlsHealthy(); err != nil {
+		t.Fatalf("expected tlsHealthy to report ok for a valid certificate, got: %s", err)
+	}
+}